@@ -0,0 +1,169 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// concurrentWorkers is how many goroutines process line batches in
+// parallel.
+const concurrentWorkers = 8
+
+// concurrentBatchBytes is the approximate size of a line batch dispatched to
+// a worker; batches are always cut on a newline boundary.
+const concurrentBatchBytes = 16 * 1024
+
+// lineBatch is a reusable unit of work: buf holds the raw bytes of one or
+// more lines, and lines are slices into buf, one per line. Pooling buf
+// avoids an allocation per batch.
+type lineBatch struct {
+	buf   []byte
+	lines [][]byte
+}
+
+var linePool = sync.Pool{
+	New: func() interface{} {
+		return &lineBatch{buf: make([]byte, 0, concurrentBatchBytes)}
+	},
+}
+
+// ImportEmailDomainConcurrent is like ImportEmailDomain but it does work
+// concurrently: in reads line batches aligned on newline boundaries and
+// dispatches them to a bounded pool of workers, which accumulate into
+// per-worker map shards that the calling goroutine merges as they arrive on
+// a fan-in channel. Unlike a
+// file-offset split, this works on any io.Reader, including stdin, a gzip
+// stream, or a network connection.
+func ImportEmailDomainConcurrent(in io.Reader) ([]Domain, error) {
+	r := bufio.NewReaderSize(in, concurrentBatchBytes)
+	header, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, errors.Errorf("reading header: %v", err)
+	}
+	emailFieldIndex, err := emailFieldIndexFromHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	batches := make(chan *lineBatch, concurrentWorkers)
+	shards := make(chan map[string]int64, concurrentWorkers)
+	errs := make(chan error, concurrentWorkers+1)
+
+	var workers sync.WaitGroup
+	workers.Add(concurrentWorkers)
+	for i := 0; i < concurrentWorkers; i++ {
+		go func() {
+			defer workers.Done()
+			shard := make(map[string]int64)
+			for batch := range batches {
+				for _, line := range batch.lines {
+					domain, err := domainFromCSVLine(line, emailFieldIndex)
+					if err != nil {
+						select {
+						case errs <- err:
+						default:
+						}
+						continue
+					}
+					shard[string(domain)]++
+				}
+				linePool.Put(batch)
+			}
+			shards <- shard
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(shards)
+	}()
+
+	if err := dispatchLineBatches(r, batches); err != nil {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	close(batches)
+
+	domainsMap := make(map[string]int64)
+	for shard := range shards {
+		for name, count := range shard {
+			domainsMap[name] += count
+		}
+	}
+
+	select {
+	case err := <-errs:
+		return nil, errors.Errorf("importing concurrently: %v", err)
+	default:
+	}
+	return getDomains(domainsMap), nil
+}
+
+// dispatchLineBatches reads r to EOF, grouping lines into ~concurrentBatchBytes
+// batches cut on newline boundaries, and sends each batch on batches.
+func dispatchLineBatches(r *bufio.Reader, batches chan<- *lineBatch) error {
+	batch := linePool.Get().(*lineBatch)
+	batch.buf = batch.buf[:0]
+	batch.lines = batch.lines[:0]
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimRight(line, "\r\n")
+			start := len(batch.buf)
+			batch.buf = append(batch.buf, line...)
+			batch.lines = append(batch.lines, batch.buf[start:len(batch.buf):len(batch.buf)])
+		}
+		if len(batch.buf) >= concurrentBatchBytes || (err != nil && len(batch.lines) > 0) {
+			batches <- batch
+			batch = linePool.Get().(*lineBatch)
+			batch.buf = batch.buf[:0]
+			batch.lines = batch.lines[:0]
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Errorf("reading input: %v", err)
+		}
+	}
+}
+
+// emailFieldIndexFromHeader finds which field of the csv header record is
+// named "email".
+func emailFieldIndexFromHeader(header string) (int, error) {
+	record, err := csv.NewReader(strings.NewReader(header)).Read()
+	if err != nil {
+		return -1, errors.Errorf("parsing csv header: %v", err)
+	}
+	for i, field := range record {
+		if field == "email" {
+			return i, nil
+		}
+	}
+	return -1, errors.New("cannot find email field in csv header")
+}
+
+// domainFromCSVLine extracts the domain of the email in the given field of
+// a single csv line, honoring RFC 4180 quoting so a quoted field containing
+// a comma (e.g. a quoted last name) is not mistaken for a field boundary.
+func domainFromCSVLine(line []byte, emailFieldIndex int) ([]byte, error) {
+	record, err := csv.NewReader(bytes.NewReader(line)).Read()
+	if err != nil {
+		return nil, errors.Errorf("parsing csv line: %v", err)
+	}
+	if emailFieldIndex >= len(record) {
+		return nil, errors.Errorf("line has no field %d", emailFieldIndex)
+	}
+	email := record[emailFieldIndex]
+	if at := strings.IndexByte(email, '@'); at >= 0 {
+		return []byte(email[at+1:]), nil
+	}
+	return nil, nil
+}
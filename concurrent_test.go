@@ -0,0 +1,82 @@
+package customerimporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportEmailDomainConcurrentReader(t *testing.T) {
+	rs := strings.TrimSpace(records)
+	got, err := ImportEmailDomainConcurrent(strings.NewReader(rs))
+	if err != nil {
+		t.Fatalf("ImportEmailDomainConcurrent error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got domains\n%v\n, expected\n%v\n", got, expected)
+	}
+}
+
+func TestImportEmailDomainConcurrentMatchesSequentialOnMalformedEmail(t *testing.T) {
+	data := strings.TrimSpace(`
+first_name,last_name,email
+M,H,mh@baz.com
+B,O,not-an-email
+`)
+	sequential, err := ImportEmailDomain(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportEmailDomain error: %v", err)
+	}
+	concurrent, err := ImportEmailDomainConcurrent(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportEmailDomainConcurrent error: %v", err)
+	}
+	if !reflect.DeepEqual(sequential, concurrent) {
+		t.Fatalf("got concurrent domains\n%v\n, sequential returned\n%v\n", concurrent, sequential)
+	}
+}
+
+func TestImportEmailDomainConcurrentHonorsQuotedFields(t *testing.T) {
+	data := "last_name,first_name,email\n\"Doe, Jane\",Jane,jd@example.com\n"
+	sequential, err := ImportEmailDomain(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportEmailDomain error: %v", err)
+	}
+	concurrent, err := ImportEmailDomainConcurrent(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportEmailDomainConcurrent error: %v", err)
+	}
+	if !reflect.DeepEqual(sequential, concurrent) {
+		t.Fatalf("got concurrent domains\n%v\n, sequential returned\n%v\n", concurrent, sequential)
+	}
+	want := []Domain{{Name: "example.com", Count: 1}}
+	if !reflect.DeepEqual(concurrent, want) {
+		t.Fatalf("got domains\n%v\n, expected\n%v\n", concurrent, want)
+	}
+}
+
+func TestEmailFieldIndexFromHeader(t *testing.T) {
+	i, err := emailFieldIndexFromHeader("first_name,last_name,email,gender,ip_address\n")
+	if err != nil {
+		t.Fatalf("emailFieldIndexFromHeader error: %v", err)
+	}
+	if i != 2 {
+		t.Fatalf("got index %d, expected 2", i)
+	}
+	if _, err := emailFieldIndexFromHeader("first_name,last_name\n"); err == nil {
+		t.Fatalf("expected an error when the header has no email field")
+	}
+}
+
+func TestDomainFromCSVLine(t *testing.T) {
+	domain, err := domainFromCSVLine([]byte("M,H,mh@github.io,Female,38.194.51.128"), 2)
+	if err != nil {
+		t.Fatalf("domainFromCSVLine error: %v", err)
+	}
+	if string(domain) != "github.io" {
+		t.Fatalf("got domain %q, expected github.io", domain)
+	}
+	if _, err := domainFromCSVLine([]byte("M,H"), 2); err == nil {
+		t.Fatalf("expected an error for a line missing the email field")
+	}
+}
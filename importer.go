@@ -0,0 +1,249 @@
+package customerimporter
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Status describes the current state of an Importer.
+type Status string
+
+const (
+	StatusNone      Status = "none"
+	StatusImporting Status = "importing"
+	StatusStopping  Status = "stopping"
+	StatusFinished  Status = "finished"
+	StatusFailed    Status = "failed"
+)
+
+// Session describes a single import run.
+type Session struct {
+	// Path is the location of a .csv, .csv.gz or .zip file. A zip file is
+	// walked and every .csv member it contains is imported.
+	Path string
+}
+
+// Progress reports how an in-flight import is advancing. It is sent on the
+// channel returned by Importer.Progress as records are read.
+type Progress struct {
+	RecordsRead  int64
+	CurrentFile  string
+	DomainsFound int
+}
+
+// Importer runs a long-running, cancellable import suitable for a web or CLI
+// front-end: only one Session runs at a time, progress is streamed over a
+// channel, and Stop cancels the in-flight work, whose goroutine then drains
+// itself.
+type Importer struct {
+	mu       sync.Mutex
+	status   Status
+	cancel   context.CancelFunc
+	result   []Domain
+	err      error
+	progress chan Progress
+}
+
+// NewImporter returns an Importer ready to run a Session.
+func NewImporter() *Importer {
+	return &Importer{
+		status:   StatusNone,
+		progress: make(chan Progress, 64),
+	}
+}
+
+// Start begins importing session in the background. It returns an error if
+// an import is already running on this Importer.
+func (imp *Importer) Start(session Session) error {
+	imp.mu.Lock()
+	if imp.status == StatusImporting || imp.status == StatusStopping {
+		imp.mu.Unlock()
+		return errors.New("import already in progress")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	imp.status = StatusImporting
+	imp.cancel = cancel
+	imp.result = nil
+	imp.err = nil
+	imp.mu.Unlock()
+
+	go imp.run(ctx, session)
+	return nil
+}
+
+// Stop cancels the in-flight import, if any, and waits for nothing: the
+// worker goroutine drains itself and moves the status to StatusNone.
+func (imp *Importer) Stop() {
+	imp.mu.Lock()
+	if imp.status != StatusImporting {
+		imp.mu.Unlock()
+		return
+	}
+	imp.status = StatusStopping
+	cancel := imp.cancel
+	imp.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Status returns the current status of the Importer.
+func (imp *Importer) Status() Status {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	return imp.status
+}
+
+// Progress returns the channel progress events are sent on. Events are
+// dropped rather than blocking the import if the caller is not reading.
+func (imp *Importer) Progress() <-chan Progress {
+	return imp.progress
+}
+
+// Result returns the domains found by the last finished import, and any
+// error the last import failed with.
+func (imp *Importer) Result() ([]Domain, error) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	return imp.result, imp.err
+}
+
+func (imp *Importer) run(ctx context.Context, session Session) {
+	domainsMap := make(map[string]int64)
+	var recordsRead int64
+	err := walkInputFiles(ctx, session.Path, func(name string, in io.Reader) error {
+		return importEmailDomainInto(ctx, in, -1, domainsMap, func() {
+			recordsRead++
+			select {
+			case imp.progress <- Progress{RecordsRead: recordsRead, CurrentFile: name, DomainsFound: len(domainsMap)}:
+			default:
+			}
+		})
+	})
+
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if err != nil {
+		if errors.Cause(err) == context.Canceled {
+			imp.status = StatusNone
+			return
+		}
+		imp.status = StatusFailed
+		imp.err = err
+		return
+	}
+	imp.result = getDomains(domainsMap)
+	imp.status = StatusFinished
+}
+
+// walkInputFiles opens path, which can be a .csv, .csv.gz or .zip file, and
+// calls fn once for every csv stream it contains, in order. For a .zip
+// archive fn is called once per member ending in ".csv".
+func walkInputFiles(ctx context.Context, path string, fn func(name string, in io.Reader) error) error {
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		return walkZip(ctx, path, fn)
+	case strings.HasSuffix(path, ".csv.gz"):
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Errorf("opening %s: %v", path, err)
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return errors.Errorf("gzip %s: %v", path, err)
+		}
+		defer gz.Close()
+		return fn(path, gz)
+	default:
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Errorf("opening %s: %v", path, err)
+		}
+		defer f.Close()
+		return fn(path, f)
+	}
+}
+
+func walkZip(ctx context.Context, path string, fn func(name string, in io.Reader) error) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.Errorf("opening zip %s: %v", path, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".csv") {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return errors.Errorf("opening zip member %s: %v", f.Name, err)
+			}
+			defer rc.Close()
+			return fn(f.Name, rc)
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importEmailDomainInto is importEmailDomain adapted to accumulate directly
+// into a caller-owned map (so several files can feed the same histogram), to
+// call onRecord after every data record, for progress reporting, and to
+// return ctx.Err() as soon as ctx is cancelled.
+func importEmailDomainInto(ctx context.Context, in io.Reader, emailFieldIndex int, domainsMap map[string]int64, onRecord func()) error {
+	r := csv.NewReader(in)
+	r.ReuseRecord = true
+	r.TrimLeadingSpace = true
+	for recordCount := 0; ; recordCount++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Errorf("reading csv record: %v %v", err, record)
+		}
+		if emailFieldIndex == -1 && recordCount == 0 {
+			for i := 0; i < len(record); i++ {
+				if record[i] == "email" {
+					emailFieldIndex = i
+					break
+				}
+			}
+			continue
+		}
+		if emailFieldIndex == -1 {
+			return errors.New("cannot find email field in csv records")
+		}
+		email := record[emailFieldIndex]
+		var emailDomain string
+		for i := 0; i < len(email); i++ {
+			if email[i] == '@' {
+				emailDomain = email[i+1:]
+				break
+			}
+		}
+		domainsMap[emailDomain]++
+		onRecord()
+	}
+}
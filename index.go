@@ -0,0 +1,173 @@
+package customerimporter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// maxSuffixLen bounds a decoded suffix length, so a truncated or corrupted
+// index file fails to decode instead of driving a runaway allocation.
+const maxSuffixLen = 1 << 16
+
+// Index is a persistent, on-disk table of domain counts, so repeated
+// imports (e.g. a weekly customer dump) can be merged into a cumulative
+// count instead of re-reading history. On disk it is stored sorted by
+// domain name, with each name prefix-compressed against the previous entry
+// and each count stored as a varint delta from the previous entry's count.
+type Index struct {
+	path    string
+	domains map[string]int64
+}
+
+// OpenIndex opens the index file at path, creating an empty index in memory
+// if it does not exist yet.
+func OpenIndex(path string) (*Index, error) {
+	idx := &Index{path: path, domains: make(map[string]int64)}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, errors.Errorf("opening index %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := decodeIndex(f, idx.domains); err != nil {
+		return nil, errors.Errorf("decoding index %s: %v", path, err)
+	}
+	return idx, nil
+}
+
+// Merge adds domains into the index and rewrites the on-disk table. A full
+// rewrite, rather than a literal append, is required because every entry is
+// prefix- and delta-compressed relative to its predecessor in sorted order,
+// and merging can change which domains are adjacent.
+func (idx *Index) Merge(domains []Domain) error {
+	for _, d := range domains {
+		idx.domains[d.Name] += d.Count
+	}
+	return idx.save()
+}
+
+// Iterate calls fn for every domain in the index, in sorted name order,
+// until fn returns false.
+func (idx *Index) Iterate(fn func(Domain) bool) {
+	for _, d := range sortedByName(idx.domains) {
+		if !fn(d) {
+			return
+		}
+	}
+}
+
+func (idx *Index) save() error {
+	tmp := idx.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Errorf("creating temp index file: %v", err)
+	}
+	if err := encodeIndex(f, sortedByName(idx.domains)); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return errors.Errorf("encoding index: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Errorf("closing temp index file: %v", err)
+	}
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return errors.Errorf("replacing index file %s: %v", idx.path, err)
+	}
+	return nil
+}
+
+func sortedByName(domainsMap map[string]int64) []Domain {
+	list := make([]Domain, 0, len(domainsMap))
+	for name, count := range domainsMap {
+		list = append(list, Domain{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// encodeIndex writes list, sorted by name, in the prefix- and
+// delta-compressed on-disk format.
+func encodeIndex(w io.Writer, list []Domain) error {
+	bw := bufio.NewWriter(w)
+	var buf [binary.MaxVarintLen64]byte
+	var prevName string
+	var prevCount int64
+	for _, d := range list {
+		prefixLen := commonPrefixLen(prevName, d.Name)
+		suffix := d.Name[prefixLen:]
+		n := binary.PutUvarint(buf[:], uint64(prefixLen))
+		if _, err := bw.Write(buf[:n]); err != nil {
+			return err
+		}
+		n = binary.PutUvarint(buf[:], uint64(len(suffix)))
+		if _, err := bw.Write(buf[:n]); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(suffix); err != nil {
+			return err
+		}
+		n = binary.PutVarint(buf[:], d.Count-prevCount)
+		if _, err := bw.Write(buf[:n]); err != nil {
+			return err
+		}
+		prevName, prevCount = d.Name, d.Count
+	}
+	return bw.Flush()
+}
+
+// decodeIndex reads entries written by encodeIndex into into.
+func decodeIndex(r io.Reader, into map[string]int64) error {
+	br := bufio.NewReader(r)
+	var prevName string
+	var prevCount int64
+	for {
+		prefixLen, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Errorf("reading prefix length: %v", err)
+		}
+		if prefixLen > uint64(len(prevName)) {
+			return errors.Errorf("corrupt index: prefix length %d exceeds previous name length %d", prefixLen, len(prevName))
+		}
+		suffixLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return errors.Errorf("reading suffix length: %v", err)
+		}
+		if suffixLen > maxSuffixLen {
+			return errors.Errorf("corrupt index: suffix length %d exceeds maximum %d", suffixLen, maxSuffixLen)
+		}
+		suffix := make([]byte, suffixLen)
+		if _, err := io.ReadFull(br, suffix); err != nil {
+			return errors.Errorf("reading suffix: %v", err)
+		}
+		delta, err := binary.ReadVarint(br)
+		if err != nil {
+			return errors.Errorf("reading count delta: %v", err)
+		}
+		name := prevName[:prefixLen] + string(suffix)
+		count := prevCount + delta
+		into[name] = count
+		prevName, prevCount = name, count
+	}
+}
+
+func commonPrefixLen(a, b string) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,50 @@
+package customerimporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// genDomains returns n synthetic, name-sorted domains, standing in for the
+// 3k-line and 1m-line corpora mentioned in the package doc.
+func genDomains(n int) []Domain {
+	list := make([]Domain, n)
+	for i := 0; i < n; i++ {
+		list[i] = Domain{Name: fmt.Sprintf("customer%d.example.com", i), Count: int64(i%50) + 1}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// BenchmarkIndexEncoding compares the size and speed of plain JSON against
+// the prefix- and delta-compressed on-disk layout, at 3k and 1m domains.
+func BenchmarkIndexEncoding(b *testing.B) {
+	for _, n := range []int{3000, 1000000} {
+		list := genDomains(n)
+		b.Run(fmt.Sprintf("JSON/%d", n), func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				data, err := json.Marshal(list)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes")
+		})
+		b.Run(fmt.Sprintf("Compact/%d", n), func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				var buf bytes.Buffer
+				if err := encodeIndex(&buf, list); err != nil {
+					b.Fatal(err)
+				}
+				size = buf.Len()
+			}
+			b.ReportMetric(float64(size), "bytes")
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package customerimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndexMergeAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.idx")
+
+	idx, err := OpenIndex(path)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	if err := idx.Merge([]Domain{
+		{Name: "github.io", Count: 2},
+		{Name: "cyberchimps.com", Count: 2},
+	}); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if err := idx.Merge([]Domain{
+		{Name: "github.io", Count: 1},
+		{Name: "acquirethisname.com", Count: 2},
+	}); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	reopened, err := OpenIndex(path)
+	if err != nil {
+		t.Fatalf("re-OpenIndex: %v", err)
+	}
+	var got []Domain
+	reopened.Iterate(func(d Domain) bool {
+		got = append(got, d)
+		return true
+	})
+	want := []Domain{
+		{Name: "acquirethisname.com", Count: 2},
+		{Name: "cyberchimps.com", Count: 2},
+		{Name: "github.io", Count: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got domains\n%v\n, expected\n%v\n", got, want)
+	}
+}
+
+func TestIndexIterateStopsEarly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.idx")
+	idx, err := OpenIndex(path)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	if err := idx.Merge([]Domain{
+		{Name: "a.com", Count: 1},
+		{Name: "b.com", Count: 1},
+		{Name: "c.com", Count: 1},
+	}); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	var seen int
+	idx.Iterate(func(Domain) bool {
+		seen++
+		return seen < 2
+	})
+	if seen != 2 {
+		t.Fatalf("got %d domains visited, expected 2", seen)
+	}
+}
+
+func TestDecodeIndexRejectsCorruptPrefixLen(t *testing.T) {
+	var buf bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+
+	writeUvarint := func(v uint64) {
+		n := binary.PutUvarint(varint[:], v)
+		buf.Write(varint[:n])
+	}
+
+	// a valid first entry: prefixLen 0, suffix "github.io", count delta 2.
+	writeUvarint(0)
+	writeUvarint(uint64(len("github.io")))
+	buf.WriteString("github.io")
+	n := binary.PutVarint(varint[:], 2)
+	buf.Write(varint[:n])
+
+	// a corrupt second entry: a prefix length larger than the previous
+	// name ("github.io" is 9 bytes long).
+	writeUvarint(100)
+	writeUvarint(0)
+	n = binary.PutVarint(varint[:], 0)
+	buf.Write(varint[:n])
+
+	into := make(map[string]int64)
+	if err := decodeIndex(&buf, into); err == nil {
+		t.Fatalf("expected decodeIndex to reject a corrupt prefix length, got no error")
+	}
+}
+
+func TestDecodeIndexRejectsOversizedSuffixLen(t *testing.T) {
+	var buf bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varint[:], 0)
+	buf.Write(varint[:n])
+	n = binary.PutUvarint(varint[:], maxSuffixLen+1)
+	buf.Write(varint[:n])
+
+	into := make(map[string]int64)
+	if err := decodeIndex(&buf, into); err == nil {
+		t.Fatalf("expected decodeIndex to reject an oversized suffix length, got no error")
+	}
+}
+
+func TestCommonPrefixLen(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "github.io", 0},
+		{"github.io", "github.io", 9},
+		{"github.io", "github.com", 7},
+		{"acme.com", "zulu.com", 0},
+	}
+	for _, c := range cases {
+		if got := commonPrefixLen(c.a, c.b); got != c.want {
+			t.Fatalf("commonPrefixLen(%q, %q) = %d, expected %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -90,7 +90,7 @@ func TestImportEmailDomainConcurrent(t *testing.T) {
 	}
 	// pass the io reader to our code and call it
 	f.Seek(0, 0)
-	domainsConcurrent, err := ImportEmailDomainConcurrent(f.Name())
+	domainsConcurrent, err := ImportEmailDomainConcurrent(f)
 	// assert that it works correctly
 	if err != nil {
 		t.Fatalf("Importing domains conccurent error: %v", err)
@@ -132,15 +132,21 @@ func BenchmarkImportEmaiDomain(b *testing.B) {
 	b.Run("ImportEmailDomainCustom", func(b *testing.B) { run(b, ImportEmailDomainCustom) })
 }
 
-// BenchmarkImportEmailDomainConcurrent-8   	     300	   5656359 ns/op	  592034 B/op	    3245 allocs/op
 func BenchmarkImportEmailDomainConcurrent(b *testing.B) {
 	var ds []Domain
-	var err error
 	for n := 0; n < b.N; n++ {
-		ds, err = ImportEmailDomainConcurrent("customers.csv")
+		b.StopTimer()
+		f, err := os.Open("customers.csv")
 		if err != nil {
+			b.Fatalf("Opening input test file : %v", err)
+		}
+		b.StartTimer()
+		ds, err = ImportEmailDomainConcurrent(f)
+		if err != nil {
+			f.Close()
 			b.Fatal(err)
 		}
+		f.Close()
 	}
 	domains = ds
 }
@@ -0,0 +1,132 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"io"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxFieldLen bounds how many bytes of an email field we will look at
+// before giving up on it, so a pathological CSV cannot blow memory.
+const defaultMaxFieldLen = 200
+
+// Options configures the validating variants of the importer.
+type Options struct {
+	// Validate turns on email address validation. Rows whose email field
+	// does not parse are passed to OnBadRecord instead of aborting the
+	// import.
+	Validate bool
+	// StrictRFC5322, when Validate is set, runs the email field through
+	// net/mail.ParseAddress, rejecting anything that is not a well-formed
+	// RFC 5322 address; it has no effect without Validate. When false,
+	// Validate only requires a single '@' with a non-empty local part and
+	// domain.
+	StrictRFC5322 bool
+	// MaxFieldLen caps how many bytes of the email field are considered;
+	// longer fields are treated as bad records. Defaults to 200.
+	MaxFieldLen int
+	// OnBadRecord, when set, is called for every row that fails
+	// validation instead of aborting the import. row is 0-based and
+	// counts data rows (the header is not counted).
+	OnBadRecord func(row int, raw []string, err error)
+}
+
+// ImportEmailDomainWithOptions is like ImportEmailDomain but validates each
+// email address per opts, routing malformed rows to opts.OnBadRecord rather
+// than failing the whole import.
+func ImportEmailDomainWithOptions(in io.Reader, opts Options) ([]Domain, error) {
+	if opts.MaxFieldLen <= 0 {
+		opts.MaxFieldLen = defaultMaxFieldLen
+	}
+	domainsMap, err := importEmailDomainValidated(in, -1, opts)
+	if err != nil {
+		return nil, err
+	}
+	return getDomains(domainsMap), nil
+}
+
+func importEmailDomainValidated(in io.Reader, emailFieldIndex int, opts Options) (map[string]int64, error) {
+	r := csv.NewReader(in)
+	r.ReuseRecord = true
+	r.TrimLeadingSpace = true
+	domainsMap := make(map[string]int64)
+	for dataRow := 0; ; {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Errorf("reading csv record: %v %v", err, record)
+		}
+		if emailFieldIndex == -1 {
+			for i := 0; i < len(record); i++ {
+				if record[i] == "email" {
+					emailFieldIndex = i
+					break
+				}
+			}
+			if emailFieldIndex == -1 {
+				return nil, errors.New("cannot find email field in csv records")
+			}
+			continue
+		}
+		email := record[emailFieldIndex]
+		domain, err := extractDomain(email, opts)
+		if err != nil {
+			if opts.OnBadRecord != nil {
+				raw := make([]string, len(record))
+				copy(raw, record)
+				opts.OnBadRecord(dataRow, raw, err)
+			}
+			dataRow++
+			continue
+		}
+		domainsMap[domain]++
+		dataRow++
+	}
+	return domainsMap, nil
+}
+
+// extractDomain validates email per opts and returns its normalized,
+// lowercased, IDNA-encoded domain.
+func extractDomain(email string, opts Options) (string, error) {
+	if opts.MaxFieldLen > 0 && len(email) > opts.MaxFieldLen {
+		return "", errors.Errorf("email field longer than %d bytes", opts.MaxFieldLen)
+	}
+	if opts.Validate {
+		if opts.StrictRFC5322 {
+			addr, err := mail.ParseAddress(email)
+			if err != nil {
+				return "", errors.Errorf("parsing email address: %v", err)
+			}
+			email = addr.Address
+		} else if !looksLikeEmail(email) {
+			return "", errors.New("not a valid email address")
+		}
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 || at == len(email)-1 {
+		return "", errors.New("email has no domain")
+	}
+	domain := strings.ToLower(email[at+1:])
+	ascii, err := idna.ToASCII(domain)
+	if err != nil {
+		return "", errors.Errorf("normalizing domain %q: %v", domain, err)
+	}
+	return ascii, nil
+}
+
+// looksLikeEmail is a permissive check used when StrictRFC5322 is not set:
+// a single '@' with a non-empty local part and domain.
+func looksLikeEmail(email string) bool {
+	at := strings.IndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return false
+	}
+	return strings.IndexByte(email[at+1:], '@') == -1
+}
@@ -0,0 +1,70 @@
+package customerimporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportEmailDomainWithOptionsBadRecords(t *testing.T) {
+	data := strings.TrimSpace(`
+first_name,last_name,email,gender,ip_address
+M,H,mh@github.io,Female,38.194.51.128
+B,O,not-an-email,Female,197.54.209.129
+D,H,dh@cyberchimps.com,Male,155.75.186.217
+`)
+	var bad []int
+	opts := Options{
+		Validate:      true,
+		StrictRFC5322: true,
+		OnBadRecord: func(row int, raw []string, err error) {
+			bad = append(bad, row)
+		},
+	}
+	got, err := ImportEmailDomainWithOptions(strings.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("ImportEmailDomainWithOptions error: %v", err)
+	}
+	want := []Domain{
+		{Name: "cyberchimps.com", Count: 1},
+		{Name: "github.io", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got domains\n%v\n, expected\n%v\n", got, want)
+	}
+	if !reflect.DeepEqual(bad, []int{1}) {
+		t.Fatalf("got bad rows %v, expected [1]", bad)
+	}
+}
+
+func TestImportEmailDomainWithOptionsMaxFieldLen(t *testing.T) {
+	longLocal := strings.Repeat("a", 300)
+	data := "email\n" + longLocal + "@github.io\n"
+	var bad []int
+	opts := Options{
+		MaxFieldLen: 50,
+		OnBadRecord: func(row int, raw []string, err error) {
+			bad = append(bad, row)
+		},
+	}
+	got, err := ImportEmailDomainWithOptions(strings.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("ImportEmailDomainWithOptions error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("got domains %v, expected none", got)
+	}
+	if !reflect.DeepEqual(bad, []int{0}) {
+		t.Fatalf("got bad rows %v, expected [0]", bad)
+	}
+}
+
+func TestExtractDomainNormalizesCase(t *testing.T) {
+	domain, err := extractDomain("user@GitHub.io", Options{})
+	if err != nil {
+		t.Fatalf("extractDomain error: %v", err)
+	}
+	if domain != "github.io" {
+		t.Fatalf("got domain %q, expected github.io", domain)
+	}
+}
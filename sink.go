@@ -0,0 +1,184 @@
+package customerimporter
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Sink receives domain counts as they are found, instead of them being
+// collected into an in-memory histogram. It lets the importer stream
+// results straight into a database or message broker, so a multi-million
+// line file never has to fit in RAM as a single map.
+type Sink interface {
+	// AddDomain records delta more occurrences of the given domain.
+	AddDomain(name string, delta int64) error
+	// Flush persists any buffered writes. It is called once the import
+	// finishes; sinks that write eagerly can make it a no-op.
+	Flush() error
+}
+
+// MapSink is a Sink that aggregates into an in-memory map, matching the
+// behavior of the original ImportEmailDomain. It is not safe for concurrent
+// use.
+type MapSink struct {
+	domains map[string]int64
+}
+
+// NewMapSink returns an empty MapSink.
+func NewMapSink() *MapSink {
+	return &MapSink{domains: make(map[string]int64)}
+}
+
+func (s *MapSink) AddDomain(name string, delta int64) error {
+	s.domains[name] += delta
+	return nil
+}
+
+func (s *MapSink) Flush() error {
+	return nil
+}
+
+// Domains returns the accumulated counts as a sorted list of Domain, in the
+// same order as getDomains.
+func (s *MapSink) Domains() []Domain {
+	return getDomains(s.domains)
+}
+
+// JSONLinesSink is a Sink that writes one JSON object per AddDomain call to
+// w, e.g. for piping into another process or a log-based message queue.
+type JSONLinesSink struct {
+	w *bufio.Writer
+}
+
+// NewJSONLinesSink returns a Sink that writes newline-delimited JSON to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: bufio.NewWriter(w)}
+}
+
+func (s *JSONLinesSink) AddDomain(name string, delta int64) error {
+	line, err := json.Marshal(Domain{Name: name, Count: delta})
+	if err != nil {
+		return errors.Errorf("marshaling domain %s: %v", name, err)
+	}
+	if _, err := s.w.Write(line); err != nil {
+		return errors.Errorf("writing domain %s: %v", name, err)
+	}
+	return s.w.WriteByte('\n')
+}
+
+func (s *JSONLinesSink) Flush() error {
+	return s.w.Flush()
+}
+
+// RedisHIncrBy is the subset of a redis client that RedisSink needs, so it
+// does not tie this package to any particular redis library.
+type RedisHIncrBy interface {
+	HIncrBy(key, field string, incr int64) (int64, error)
+}
+
+// RedisSink is a Sink that increments a redis hash field per domain via
+// HINCRBY.
+type RedisSink struct {
+	client RedisHIncrBy
+	key    string
+}
+
+// NewRedisSink returns a Sink that HINCRBYs into the redis hash key.
+func NewRedisSink(client RedisHIncrBy, key string) *RedisSink {
+	return &RedisSink{client: client, key: key}
+}
+
+func (s *RedisSink) AddDomain(name string, delta int64) error {
+	if _, err := s.client.HIncrBy(s.key, name, delta); err != nil {
+		return errors.Errorf("HINCRBY %s %s: %v", s.key, name, err)
+	}
+	return nil
+}
+
+func (s *RedisSink) Flush() error {
+	return nil
+}
+
+// defaultPostgresBatchSize is how many upserts PostgresSink commits in a
+// single transaction.
+const defaultPostgresBatchSize = 10000
+
+// validPostgresIdentifier restricts table names accepted by NewPostgresSink
+// to a safe subset, since the name is interpolated into the upsert query
+// text (the postgres driver has no placeholder syntax for identifiers).
+var validPostgresIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// PostgresSink is a Sink that upserts domain counts into a postgres table
+// with columns (domain text primary key, count bigint), committing every
+// batchSize records in a single transaction rather than one per row.
+type PostgresSink struct {
+	db        *sql.DB
+	table     string
+	batchSize int
+
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+}
+
+// NewPostgresSink returns a Sink that upserts into table using db, batching
+// defaultPostgresBatchSize rows per transaction. table must match
+// validPostgresIdentifier, since it is interpolated into the upsert query
+// rather than passed as a bound parameter.
+func NewPostgresSink(db *sql.DB, table string) (*PostgresSink, error) {
+	if !validPostgresIdentifier.MatchString(table) {
+		return nil, errors.Errorf("invalid table name %q: must match %s", table, validPostgresIdentifier.String())
+	}
+	return &PostgresSink{db: db, table: table, batchSize: defaultPostgresBatchSize}, nil
+}
+
+func (s *PostgresSink) AddDomain(name string, delta int64) error {
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return errors.Errorf("beginning batch transaction: %v", err)
+		}
+		query := fmt.Sprintf(
+			`INSERT INTO %s (domain, count) VALUES ($1, $2)
+			 ON CONFLICT (domain) DO UPDATE SET count = %s.count + excluded.count`,
+			s.table, s.table)
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			tx.Rollback()
+			return errors.Errorf("preparing upsert: %v", err)
+		}
+		s.tx, s.stmt = tx, stmt
+	}
+	if _, err := s.stmt.Exec(name, delta); err != nil {
+		s.stmt.Close()
+		s.tx.Rollback()
+		s.tx, s.stmt, s.pending = nil, nil, 0
+		return errors.Errorf("upserting domain %s: %v", name, err)
+	}
+	s.pending++
+	if s.pending >= s.batchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *PostgresSink) Flush() error {
+	if s.tx == nil {
+		return nil
+	}
+	if err := s.stmt.Close(); err != nil {
+		return errors.Errorf("closing upsert statement: %v", err)
+	}
+	err := s.tx.Commit()
+	s.tx, s.stmt, s.pending = nil, nil, 0
+	if err != nil {
+		return errors.Errorf("committing batch: %v", err)
+	}
+	return nil
+}
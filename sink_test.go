@@ -0,0 +1,73 @@
+package customerimporter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportEmailDomainToSinkMapSink(t *testing.T) {
+	rs := strings.TrimSpace(records)
+	sink := NewMapSink()
+	if err := ImportEmailDomainToSink(strings.NewReader(rs), sink); err != nil {
+		t.Fatalf("ImportEmailDomainToSink error: %v", err)
+	}
+	if !reflect.DeepEqual(sink.Domains(), expected) {
+		t.Fatalf("got domains\n%v\n, expected\n%v\n", sink.Domains(), expected)
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+	if err := sink.AddDomain("github.io", 2); err != nil {
+		t.Fatalf("AddDomain error: %v", err)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	want := `{"Name":"github.io","Count":2}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, expected %q", buf.String(), want)
+	}
+}
+
+type fakeRedisClient struct {
+	calls map[string]int64
+}
+
+func (c *fakeRedisClient) HIncrBy(key, field string, incr int64) (int64, error) {
+	if c.calls == nil {
+		c.calls = make(map[string]int64)
+	}
+	c.calls[key+":"+field] += incr
+	return c.calls[key+":"+field], nil
+}
+
+func TestRedisSink(t *testing.T) {
+	client := &fakeRedisClient{}
+	sink := NewRedisSink(client, "domains")
+	if err := sink.AddDomain("github.io", 1); err != nil {
+		t.Fatalf("AddDomain error: %v", err)
+	}
+	if err := sink.AddDomain("github.io", 1); err != nil {
+		t.Fatalf("AddDomain error: %v", err)
+	}
+	if got := client.calls["domains:github.io"]; got != 2 {
+		t.Fatalf("got count %d, expected 2", got)
+	}
+}
+
+func TestNewPostgresSinkRejectsInvalidTableName(t *testing.T) {
+	// db is never dialed for a rejected table name, so nil is fine here.
+	cases := []string{"domains; drop table users", "domains-2024", "1domains", ""}
+	for _, table := range cases {
+		if _, err := NewPostgresSink(nil, table); err == nil {
+			t.Fatalf("expected NewPostgresSink to reject table name %q", table)
+		}
+	}
+	if _, err := NewPostgresSink(nil, "domain_counts"); err != nil {
+		t.Fatalf("NewPostgresSink rejected a valid table name: %v", err)
+	}
+}